@@ -19,7 +19,9 @@ import (
 func addPlatformDependentCmds(
 	fs afero.Fs, mgr config.Manager, cmd *cobra.Command,
 ) {
-	cmd.AddCommand(NewTuneCommand(fs, mgr))
+	tuneCmd := NewTuneCommand(fs, mgr)
+	tuneCmd.AddCommand(NewTuneRollbackCommand(fs, mgr))
+	cmd.AddCommand(tuneCmd)
 	cmd.AddCommand(NewCheckCommand(fs, mgr))
 	cmd.AddCommand(NewIoTuneCmd(fs, mgr))
 	cmd.AddCommand(NewStartCommand(fs, mgr))
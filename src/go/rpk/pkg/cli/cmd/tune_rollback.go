@@ -0,0 +1,33 @@
+// Copyright 2020 Vectorized, Inc.
+//
+// Use of this software is governed by the Business Source License
+// included in the file licenses/BSL.md
+//
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the Apache License, Version 2.0
+
+package cmd
+
+import (
+	"vectorized/pkg/config"
+
+	"github.com/spf13/afero"
+	"github.com/spf13/cobra"
+	"github.com/vectorizedio/redpanda/src/go/rpk/pkg/tuners/executors"
+)
+
+func NewTuneRollbackCommand(fs afero.Fs, mgr config.Manager) *cobra.Command {
+	return &cobra.Command{
+		Use:   "rollback",
+		Short: "Undo the tuners applied by a previous, crashed `rpk tune` run",
+		RunE: func(ccmd *cobra.Command, args []string) error {
+			cfg, err := mgr.Get()
+			if err != nil {
+				return err
+			}
+			journalPath := executors.JournalPath(cfg.Redpanda.Directory)
+			return executors.Rollback(fs, journalPath, executors.NewDirectExecutor())
+		},
+	}
+}
@@ -10,28 +10,43 @@
 package tuners
 
 import (
+	"errors"
 	"fmt"
+	"os"
+	"syscall"
 
 	log "github.com/sirupsen/logrus"
 	"github.com/spf13/afero"
+	"github.com/vectorizedio/redpanda/src/go/rpk/pkg/tuners/cgroups"
 	"github.com/vectorizedio/redpanda/src/go/rpk/pkg/tuners/executors"
 	"github.com/vectorizedio/redpanda/src/go/rpk/pkg/tuners/executors/commands"
 	"github.com/vectorizedio/redpanda/src/go/rpk/pkg/utils"
 )
 
-const maxAIOEvents = 1048576
+const maxAIOEventsPerCPU = 1048576 / 4
 const maxAIOEventsFile = "/proc/sys/fs/aio-max-nr"
 
-func NewMaxAIOEventsChecker(fs afero.Fs) Checker {
+// maxAIOEventsTarget scales the historical host-wide default
+// (1048576, tuned for a 4 CPU host) to the container's effective CPU
+// allotment, so that `rpk tune` doesn't ask for a host-sized aio-max-nr
+// inside a small container.
+func maxAIOEventsTarget(resources *cgroups.Resources) int {
+	return maxAIOEventsPerCPU * resources.EffectiveCPUs
+}
+
+func NewMaxAIOEventsChecker(
+	fs afero.Fs, resources *cgroups.Resources,
+) Checker {
+	target := maxAIOEventsTarget(resources)
 	return NewIntChecker(
 		MaxAIOEvents,
 		"Max AIO Events",
 		Warning,
 		func(current int) bool {
-			return current >= maxAIOEvents
+			return current >= target
 		},
 		func() string {
-			return fmt.Sprintf(">= %d", maxAIOEvents)
+			return fmt.Sprintf(">= %d (%s)", target, resources.String())
 		},
 		func() (int, error) {
 			return utils.ReadIntFromFile(fs, maxAIOEventsFile)
@@ -39,19 +54,51 @@ func NewMaxAIOEventsChecker(fs afero.Fs) Checker {
 	)
 }
 
-func NewMaxAIOEventsTuner(fs afero.Fs, executor executors.Executor) Tunable {
+func NewMaxAIOEventsTuner(
+	fs afero.Fs, executor executors.Executor, resources *cgroups.Resources,
+) Tunable {
+	target := maxAIOEventsTarget(resources)
+	checker := NewMaxAIOEventsChecker(fs, resources)
 	return NewCheckedTunable(
-		NewMaxAIOEventsChecker(fs),
+		checker,
 		func() TuneResult {
-			log.Debugf("Setting max AIO events to %d", maxAIOEvents)
-			err := executor.Execute(
-				commands.NewWriteFileCmd(
-					fs,
-					maxAIOEventsFile,
-					fmt.Sprint(maxAIOEvents),
-				),
+			log.Debugf(
+				"Setting max AIO events to %d (%s)",
+				target,
+				resources.String(),
+			)
+			cmd := commands.NewWriteFileCmd(
+				fs,
+				maxAIOEventsFile,
+				fmt.Sprint(target),
 			)
+			verify := func() error {
+				result := checker.Check()
+				if !result.IsOk {
+					return fmt.Errorf(
+						"max AIO events still below target after tuning: %v",
+						result.Current,
+					)
+				}
+				return nil
+			}
+
+			var err error
+			if verifying, ok := executor.(executors.VerifyingExecutor); ok {
+				err = verifying.ExecuteAndVerify(cmd, verify)
+			} else {
+				err = executor.Execute(cmd)
+			}
 			if err != nil {
+				if os.IsPermission(err) || errors.Is(err, syscall.EROFS) {
+					log.Warnf(
+						"Can't write %s from inside this container: %v; "+
+							"set it on the host, or from a privileged init container",
+						maxAIOEventsFile,
+						err,
+					)
+					return NewTuneResult(false)
+				}
 				return NewTuneError(err)
 			}
 			return NewTuneResult(false)
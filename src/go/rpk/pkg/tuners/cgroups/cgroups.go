@@ -0,0 +1,361 @@
+// Copyright 2020 Vectorized, Inc.
+//
+// Use of this software is governed by the Business Source License
+// included in the file licenses/BSL.md
+//
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the Apache License, Version 2.0
+
+// Package cgroups detects the cgroup v1/v2 hierarchy the current process is
+// running under and derives the effective CPU and memory allotment from it,
+// so that tuners can size their targets to a container's quota rather than
+// the host's.
+package cgroups
+
+import (
+	"bufio"
+	"fmt"
+	"math"
+	"runtime"
+	"strconv"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/afero"
+)
+
+// Version identifies which cgroup implementation is in effect for the
+// current process.
+type Version int
+
+const (
+	// Unavailable is returned when no cgroup hierarchy could be located,
+	// e.g. when running directly on a host without cgroups mounted.
+	Unavailable Version = iota
+	V1
+	V2
+)
+
+const (
+	selfCgroupFile    = "/proc/self/cgroup"
+	selfMountinfo     = "/proc/self/mountinfo"
+	v2CPUMaxFile      = "cpu.max"
+	v2MemoryMaxFile   = "memory.max"
+	v1CPUQuotaFile    = "cpu.cfs_quota_us"
+	v1CPUPeriodFile   = "cpu.cfs_period_us"
+	v1MemoryLimitFile = "memory.limit_in_bytes"
+)
+
+// Resources holds the resource allotment derived from the current process'
+// cgroup, and which cgroup version it was read from.
+type Resources struct {
+	Version Version
+	// EffectiveCPUs is ceil(quota/period), floored at 1. When the CPU
+	// controller reports no quota ("-1"/"max"), it falls back to
+	// runtime.NumCPU().
+	EffectiveCPUs int
+	// EffectiveMemoryBytes is the memory limit in bytes, or -1 when the
+	// memory controller reports no limit ("max", or v1's "unbounded"
+	// sentinel of math.MaxInt64 rounded to a page boundary).
+	EffectiveMemoryBytes int64
+}
+
+// String renders the detected effective CPU and memory allotment, for
+// surfacing in `rpk check` checker descriptions.
+func (r *Resources) String() string {
+	memory := "unbounded"
+	if r.EffectiveMemoryBytes >= 0 {
+		memory = fmt.Sprintf("%d bytes", r.EffectiveMemoryBytes)
+	}
+	return fmt.Sprintf("%d effective CPUs, %s effective memory", r.EffectiveCPUs, memory)
+}
+
+// mountPoint describes a single line of /proc/self/mountinfo relevant to
+// locating a cgroup controller's mount point.
+type mountPoint struct {
+	root       string
+	mountPoint string
+	fsType     string
+	superOpts  string
+}
+
+// DetectResources locates the effective cgroup v1 or v2 hierarchy for the
+// current process and reads its CPU and memory controllers. When no cgroup
+// hierarchy is found (e.g. running outside of a container), it returns a
+// Resources with Version Unavailable and host-derived defaults so that
+// callers can use it unconditionally.
+func DetectResources(fs afero.Fs) (*Resources, error) {
+	mounts, err := parseMountinfo(fs, selfMountinfo)
+	if err != nil {
+		return hostDefaults(), err
+	}
+	cgroupPaths, err := parseSelfCgroup(fs, selfCgroupFile)
+	if err != nil {
+		return hostDefaults(), err
+	}
+
+	if cpuMp, cpuPath, ok := findController(mounts, cgroupPaths, "cpu"); ok {
+		memMp, memPath, ok := findController(mounts, cgroupPaths, "memory")
+		if !ok {
+			// No separate memory hierarchy (e.g. a "cpu" combined
+			// controller without "memory"); fall back to the CPU
+			// controller's own mount, matching single-hierarchy setups.
+			memMp, memPath = cpuMp, cpuPath
+		}
+		return readV1(fs, cpuMp, cpuPath, memMp, memPath)
+	}
+	if mp, path, ok := findUnified(mounts, cgroupPaths); ok {
+		return readV2(fs, mp, path)
+	}
+
+	log.Debug("No cgroup v1 or v2 hierarchy found, falling back to host resources")
+	return hostDefaults(), nil
+}
+
+func hostDefaults() *Resources {
+	return &Resources{
+		Version:              Unavailable,
+		EffectiveCPUs:        runtime.NumCPU(),
+		EffectiveMemoryBytes: -1,
+	}
+}
+
+func parseMountinfo(fs afero.Fs, path string) ([]mountPoint, error) {
+	f, err := fs.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var mounts []mountPoint
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		// Format (man 5 proc): fields separated by spaces, with a
+		// "-" field separating the variable-length optional fields
+		// from the fixed trailing fields:
+		// ... root mountPoint mountOpts ... - fsType source superOpts
+		fields := strings.Fields(scanner.Text())
+		sepIdx := -1
+		for i, f := range fields {
+			if f == "-" {
+				sepIdx = i
+				break
+			}
+		}
+		if sepIdx < 0 || sepIdx < 4 || len(fields) < sepIdx+4 {
+			continue
+		}
+		mounts = append(mounts, mountPoint{
+			root:       fields[3],
+			mountPoint: fields[4],
+			fsType:     fields[sepIdx+1],
+			superOpts:  fields[sepIdx+3],
+		})
+	}
+	return mounts, scanner.Err()
+}
+
+// parseSelfCgroup parses /proc/self/cgroup, returning a map of controller
+// name (v1) or "" (v2, single unified entry) to the process' cgroup path
+// within that hierarchy.
+func parseSelfCgroup(fs afero.Fs, path string) (map[string]string, error) {
+	f, err := fs.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	paths := map[string]string{}
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		// Format: hierarchy-ID:controller-list:cgroup-path
+		parts := strings.SplitN(scanner.Text(), ":", 3)
+		if len(parts) != 3 {
+			continue
+		}
+		controllers := parts[1]
+		cgroupPath := parts[2]
+		if controllers == "" {
+			paths[""] = cgroupPath
+			continue
+		}
+		for _, controller := range strings.Split(controllers, ",") {
+			paths[controller] = cgroupPath
+		}
+	}
+	return paths, scanner.Err()
+}
+
+func findController(
+	mounts []mountPoint, cgroupPaths map[string]string, controller string,
+) (mountPoint, string, bool) {
+	cgroupPath, ok := cgroupPaths[controller]
+	if !ok {
+		return mountPoint{}, "", false
+	}
+	for _, m := range mounts {
+		if m.fsType != "cgroup" {
+			continue
+		}
+		for _, opt := range strings.Split(m.superOpts, ",") {
+			if opt == controller {
+				return m, cgroupPath, true
+			}
+		}
+	}
+	return mountPoint{}, "", false
+}
+
+func findUnified(
+	mounts []mountPoint, cgroupPaths map[string]string,
+) (mountPoint, string, bool) {
+	cgroupPath, ok := cgroupPaths[""]
+	if !ok {
+		return mountPoint{}, "", false
+	}
+	for _, m := range mounts {
+		if m.fsType == "cgroup2" {
+			return m, cgroupPath, true
+		}
+	}
+	return mountPoint{}, "", false
+}
+
+func readV1(
+	fs afero.Fs,
+	cpuMp mountPoint, cpuCgroupPath string,
+	memMp mountPoint, memCgroupPath string,
+) (*Resources, error) {
+	cpuBase := joinCgroupPath(cpuMp, cpuCgroupPath)
+	memBase := joinCgroupPath(memMp, memCgroupPath)
+
+	quota, err := readInt64(fs, cpuBase+"/"+v1CPUQuotaFile)
+	if err != nil {
+		return hostDefaults(), err
+	}
+	period, err := readInt64(fs, cpuBase+"/"+v1CPUPeriodFile)
+	if err != nil {
+		return hostDefaults(), err
+	}
+
+	memLimit, err := readInt64(fs, memBase+"/"+v1MemoryLimitFile)
+	if err != nil {
+		return hostDefaults(), err
+	}
+
+	return &Resources{
+		Version:              V1,
+		EffectiveCPUs:        effectiveCPUs(quota, period),
+		EffectiveMemoryBytes: effectiveMemory(memLimit),
+	}, nil
+}
+
+func readV2(fs afero.Fs, mp mountPoint, cgroupPath string) (*Resources, error) {
+	base := joinCgroupPath(mp, cgroupPath)
+
+	quota, period, err := readCPUMax(fs, base+"/"+v2CPUMaxFile)
+	if err != nil {
+		return hostDefaults(), err
+	}
+
+	memLimit, err := readMemoryMax(fs, base+"/"+v2MemoryMaxFile)
+	if err != nil {
+		return hostDefaults(), err
+	}
+
+	return &Resources{
+		Version:              V2,
+		EffectiveCPUs:        effectiveCPUs(quota, period),
+		EffectiveMemoryBytes: effectiveMemory(memLimit),
+	}, nil
+}
+
+// joinCgroupPath resolves the cgroup's on-disk directory given the mount
+// point entry and the process' cgroup path reported in /proc/self/cgroup.
+func joinCgroupPath(mp mountPoint, cgroupPath string) string {
+	// The mount exposes only the subtree under mp.root, e.g. a container's
+	// own cgroup mounted at the mount point.
+	if mp.root != "" && mp.root != "/" &&
+		(cgroupPath == mp.root || strings.HasPrefix(cgroupPath, mp.root+"/")) {
+		cgroupPath = strings.TrimPrefix(cgroupPath, mp.root)
+	}
+	if cgroupPath == "" || cgroupPath == "/" {
+		return mp.mountPoint
+	}
+	return strings.TrimSuffix(mp.mountPoint, "/") + cgroupPath
+}
+
+func readInt64(fs afero.Fs, path string) (int64, error) {
+	b, err := afero.ReadFile(fs, path)
+	if err != nil {
+		return 0, err
+	}
+	return strconv.ParseInt(strings.TrimSpace(string(b)), 10, 64)
+}
+
+// readCPUMax reads a cgroup v2 "cpu.max" file, which contains a single line
+// of "$MAX $PERIOD", where $MAX is either an integer or the literal "max".
+func readCPUMax(fs afero.Fs, path string) (quota, period int64, err error) {
+	b, err := afero.ReadFile(fs, path)
+	if err != nil {
+		return 0, 0, err
+	}
+	fields := strings.Fields(strings.TrimSpace(string(b)))
+	if len(fields) != 2 {
+		return -1, 100000, nil
+	}
+	if fields[0] == "max" {
+		quota = -1
+	} else {
+		quota, err = strconv.ParseInt(fields[0], 10, 64)
+		if err != nil {
+			return 0, 0, err
+		}
+	}
+	period, err = strconv.ParseInt(fields[1], 10, 64)
+	if err != nil {
+		return 0, 0, err
+	}
+	return quota, period, nil
+}
+
+// readMemoryMax reads a cgroup v2 "memory.max" file, which is either an
+// integer or the literal "max".
+func readMemoryMax(fs afero.Fs, path string) (int64, error) {
+	b, err := afero.ReadFile(fs, path)
+	if err != nil {
+		return 0, err
+	}
+	s := strings.TrimSpace(string(b))
+	if s == "max" {
+		return -1, nil
+	}
+	return strconv.ParseInt(s, 10, 64)
+}
+
+// effectiveCPUs derives an integer CPU count from a cfs quota/period pair,
+// as ceil(quota/period), floored at 1. A quota of -1 (no limit) passes
+// through to the host's CPU count.
+func effectiveCPUs(quota, period int64) int {
+	if quota <= 0 || period <= 0 {
+		return runtime.NumCPU()
+	}
+	cpus := int(math.Ceil(float64(quota) / float64(period)))
+	if cpus < 1 {
+		return 1
+	}
+	return cpus
+}
+
+// effectiveMemory normalizes a cgroup memory limit to bytes, returning -1
+// when the controller reports no limit (v2's "max", or v1's practice of
+// reporting a very large sentinel value close to the architecture's max
+// page-aligned int64).
+func effectiveMemory(limit int64) int64 {
+	const v1UnboundedThreshold = math.MaxInt64 - (1 << 20)
+	if limit < 0 || limit >= v1UnboundedThreshold {
+		return -1
+	}
+	return limit
+}
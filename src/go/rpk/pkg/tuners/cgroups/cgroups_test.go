@@ -0,0 +1,101 @@
+// Copyright 2020 Vectorized, Inc.
+//
+// Use of this software is governed by the Business Source License
+// included in the file licenses/BSL.md
+//
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the Apache License, Version 2.0
+
+package cgroups
+
+import (
+	"math"
+	"runtime"
+	"testing"
+
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEffectiveCPUs(t *testing.T) {
+	tests := []struct {
+		name          string
+		quota, period int64
+		expected      int
+	}{
+		{"exact multiple", 200000, 100000, 2},
+		{"rounds up", 150000, 100000, 2},
+		{"floors at 1 for a tiny quota", 1, 100000, 1},
+		{"no quota passes through to NumCPU", -1, 100000, runtime.NumCPU()},
+		{"zero period passes through to NumCPU", 200000, 0, runtime.NumCPU()},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.Equal(t, tt.expected, effectiveCPUs(tt.quota, tt.period))
+		})
+	}
+}
+
+func TestEffectiveMemory(t *testing.T) {
+	require.Equal(t, int64(512*1024*1024), effectiveMemory(512*1024*1024))
+	require.Equal(t, int64(-1), effectiveMemory(-1))
+	require.Equal(t, int64(-1), effectiveMemory(math.MaxInt64))
+}
+
+func TestResourcesString(t *testing.T) {
+	bounded := &Resources{EffectiveCPUs: 2, EffectiveMemoryBytes: 1073741824}
+	require.Equal(t, "2 effective CPUs, 1073741824 bytes effective memory", bounded.String())
+
+	unbounded := &Resources{EffectiveCPUs: 4, EffectiveMemoryBytes: -1}
+	require.Equal(t, "4 effective CPUs, unbounded effective memory", unbounded.String())
+}
+
+func TestDetectResourcesV1SeparateHierarchies(t *testing.T) {
+	fs := afero.NewMemMapFs()
+	writeFile(t, fs, "/proc/self/mountinfo",
+		"25 30 0:22 / /sys/fs/cgroup/cpu,cpuacct rw - cgroup cgroup rw,cpu,cpuacct\n"+
+			"26 30 0:23 / /sys/fs/cgroup/memory rw - cgroup cgroup rw,memory\n")
+	writeFile(t, fs, "/proc/self/cgroup",
+		"5:cpu,cpuacct:/docker/abc\n"+
+			"4:memory:/docker/abc\n")
+	writeFile(t, fs, "/sys/fs/cgroup/cpu,cpuacct/docker/abc/cpu.cfs_quota_us", "200000")
+	writeFile(t, fs, "/sys/fs/cgroup/cpu,cpuacct/docker/abc/cpu.cfs_period_us", "100000")
+	writeFile(t, fs, "/sys/fs/cgroup/memory/docker/abc/memory.limit_in_bytes", "1073741824")
+
+	resources, err := DetectResources(fs)
+	require.NoError(t, err)
+	require.Equal(t, V1, resources.Version)
+	require.Equal(t, 2, resources.EffectiveCPUs)
+	require.Equal(t, int64(1073741824), resources.EffectiveMemoryBytes)
+}
+
+func TestDetectResourcesV2Unified(t *testing.T) {
+	fs := afero.NewMemMapFs()
+	writeFile(t, fs, "/proc/self/mountinfo",
+		"25 30 0:22 / /sys/fs/cgroup rw - cgroup2 cgroup2 rw\n")
+	writeFile(t, fs, "/proc/self/cgroup", "0::/docker/abc\n")
+	writeFile(t, fs, "/sys/fs/cgroup/docker/abc/cpu.max", "150000 100000")
+	writeFile(t, fs, "/sys/fs/cgroup/docker/abc/memory.max", "max")
+
+	resources, err := DetectResources(fs)
+	require.NoError(t, err)
+	require.Equal(t, V2, resources.Version)
+	require.Equal(t, 2, resources.EffectiveCPUs)
+	require.Equal(t, int64(-1), resources.EffectiveMemoryBytes)
+}
+
+func TestDetectResourcesFallsBackWithoutCgroups(t *testing.T) {
+	fs := afero.NewMemMapFs()
+
+	resources, err := DetectResources(fs)
+	require.Error(t, err)
+	require.Equal(t, Unavailable, resources.Version)
+	require.Equal(t, runtime.NumCPU(), resources.EffectiveCPUs)
+	require.Equal(t, int64(-1), resources.EffectiveMemoryBytes)
+}
+
+func writeFile(t *testing.T, fs afero.Fs, path, content string) {
+	t.Helper()
+	require.NoError(t, afero.WriteFile(fs, path, []byte(content), 0644))
+}
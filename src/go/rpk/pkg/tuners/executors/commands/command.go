@@ -0,0 +1,52 @@
+// Copyright 2020 Vectorized, Inc.
+//
+// Use of this software is governed by the Business Source License
+// included in the file licenses/BSL.md
+//
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the Apache License, Version 2.0
+
+package commands
+
+// Command is a single system-tuning operation, runnable directly or
+// renderable as a shell script line for the lazy executor.
+type Command interface {
+	Execute() error
+	RenderScript() string
+}
+
+// Reversible is implemented by commands that can capture their own
+// pre-image, so that an executor can undo them later.
+type Reversible interface {
+	Command
+	// Capture returns the Command that restores the state Command.Execute
+	// is about to change, as observed right before Execute runs.
+	Capture() (Command, error)
+}
+
+// Kind of Command, recorded in a JournalEntry so it can be reconstructed by
+// FromEntry.
+type Kind string
+
+const (
+	KindWriteFile  Kind = "write_file"
+	KindRemoveFile Kind = "remove_file"
+)
+
+// JournalEntry is the serializable form of a Command, used to persist an
+// executor's rollback journal to disk and reconstruct real Command values
+// from it, e.g. after a crash.
+type JournalEntry struct {
+	Kind    Kind   `json:"kind"`
+	Path    string `json:"path"`
+	Content string `json:"content,omitempty"`
+}
+
+// Recordable is implemented by commands that can describe themselves as a
+// JournalEntry, so a journal can persist and later replay them as real
+// Command values instead of opaque rendered text.
+type Recordable interface {
+	Command
+	Entry() JournalEntry
+}
@@ -0,0 +1,52 @@
+// Copyright 2020 Vectorized, Inc.
+//
+// Use of this software is governed by the Business Source License
+// included in the file licenses/BSL.md
+//
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the Apache License, Version 2.0
+
+package commands
+
+import (
+	"fmt"
+
+	"github.com/spf13/afero"
+)
+
+type removeFileCmd struct {
+	fs   afero.Fs
+	path string
+}
+
+// NewRemoveFileCmd returns a Command that removes the file at path.
+func NewRemoveFileCmd(fs afero.Fs, path string) Command {
+	return &removeFileCmd{fs, path}
+}
+
+func (cmd *removeFileCmd) Execute() error {
+	return cmd.fs.Remove(cmd.path)
+}
+
+func (cmd *removeFileCmd) RenderScript() string {
+	return fmt.Sprintf("rm -f %s", cmd.path)
+}
+
+func (cmd *removeFileCmd) Entry() JournalEntry {
+	return JournalEntry{Kind: KindRemoveFile, Path: cmd.path}
+}
+
+// FromEntry reconstructs the real Command a JournalEntry was recorded from,
+// bound to fs, so a rollback journal can be replayed after a crash without
+// the original in-process Command values.
+func FromEntry(fs afero.Fs, entry JournalEntry) (Command, error) {
+	switch entry.Kind {
+	case KindWriteFile:
+		return NewWriteFileCmd(fs, entry.Path, entry.Content), nil
+	case KindRemoveFile:
+		return NewRemoveFileCmd(fs, entry.Path), nil
+	default:
+		return nil, fmt.Errorf("unknown journal entry kind %q", entry.Kind)
+	}
+}
@@ -0,0 +1,55 @@
+// Copyright 2020 Vectorized, Inc.
+//
+// Use of this software is governed by the Business Source License
+// included in the file licenses/BSL.md
+//
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the Apache License, Version 2.0
+
+package commands
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/spf13/afero"
+)
+
+type writeFileCmd struct {
+	fs      afero.Fs
+	path    string
+	content string
+}
+
+// NewWriteFileCmd returns a Command that overwrites the file at path with
+// content.
+func NewWriteFileCmd(fs afero.Fs, path string, content string) Command {
+	return &writeFileCmd{fs, path, content}
+}
+
+func (cmd *writeFileCmd) Execute() error {
+	return afero.WriteFile(cmd.fs, cmd.path, []byte(cmd.content), 0644)
+}
+
+func (cmd *writeFileCmd) RenderScript() string {
+	return fmt.Sprintf("echo '%s' > %s", cmd.content, cmd.path)
+}
+
+func (cmd *writeFileCmd) Entry() JournalEntry {
+	return JournalEntry{Kind: KindWriteFile, Path: cmd.path, Content: cmd.content}
+}
+
+// Capture reads the current content of the file cmd is about to overwrite,
+// and returns the Command that restores it. If the file doesn't exist yet,
+// the returned Command removes it instead of writing it back.
+func (cmd *writeFileCmd) Capture() (Command, error) {
+	prior, err := afero.ReadFile(cmd.fs, cmd.path)
+	if os.IsNotExist(err) {
+		return NewRemoveFileCmd(cmd.fs, cmd.path), nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return NewWriteFileCmd(cmd.fs, cmd.path, string(prior)), nil
+}
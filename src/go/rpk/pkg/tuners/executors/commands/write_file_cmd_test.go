@@ -0,0 +1,71 @@
+// Copyright 2020 Vectorized, Inc.
+//
+// Use of this software is governed by the Business Source License
+// included in the file licenses/BSL.md
+//
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the Apache License, Version 2.0
+
+package commands
+
+import (
+	"testing"
+
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWriteFileCmdCaptureOfExistingFile(t *testing.T) {
+	fs := afero.NewMemMapFs()
+	require.NoError(t, afero.WriteFile(fs, "/a", []byte("original"), 0644))
+
+	cmd := NewWriteFileCmd(fs, "/a", "tuned")
+	rollback, err := cmd.(Reversible).Capture()
+	require.NoError(t, err)
+
+	require.NoError(t, cmd.Execute())
+	a, err := afero.ReadFile(fs, "/a")
+	require.NoError(t, err)
+	require.Equal(t, "tuned", string(a))
+
+	require.NoError(t, rollback.Execute())
+	a, err = afero.ReadFile(fs, "/a")
+	require.NoError(t, err)
+	require.Equal(t, "original", string(a))
+}
+
+func TestWriteFileCmdCaptureOfMissingFileRollsBackToRemoval(t *testing.T) {
+	fs := afero.NewMemMapFs()
+
+	cmd := NewWriteFileCmd(fs, "/a", "tuned")
+	rollback, err := cmd.(Reversible).Capture()
+	require.NoError(t, err)
+	require.NoError(t, cmd.Execute())
+
+	require.NoError(t, rollback.Execute())
+	exists, err := afero.Exists(fs, "/a")
+	require.NoError(t, err)
+	require.False(t, exists)
+}
+
+func TestFromEntryRoundTrip(t *testing.T) {
+	fs := afero.NewMemMapFs()
+
+	write, err := FromEntry(fs, JournalEntry{Kind: KindWriteFile, Path: "/a", Content: "x"})
+	require.NoError(t, err)
+	require.NoError(t, write.Execute())
+	a, err := afero.ReadFile(fs, "/a")
+	require.NoError(t, err)
+	require.Equal(t, "x", string(a))
+
+	remove, err := FromEntry(fs, JournalEntry{Kind: KindRemoveFile, Path: "/a"})
+	require.NoError(t, err)
+	require.NoError(t, remove.Execute())
+	exists, err := afero.Exists(fs, "/a")
+	require.NoError(t, err)
+	require.False(t, exists)
+
+	_, err = FromEntry(fs, JournalEntry{Kind: "bogus"})
+	require.Error(t, err)
+}
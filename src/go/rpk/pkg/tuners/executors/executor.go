@@ -0,0 +1,51 @@
+// Copyright 2020 Vectorized, Inc.
+//
+// Use of this software is governed by the Business Source License
+// included in the file licenses/BSL.md
+//
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the Apache License, Version 2.0
+
+// Package executors provides the strategies tuners use to apply a
+// commands.Command: running it directly, rendering it as a shell script for
+// a later privileged run, or running it transactionally with rollback.
+package executors
+
+import (
+	"github.com/vectorizedio/redpanda/src/go/rpk/pkg/tuners/executors/commands"
+)
+
+// Executor applies commands.Command values produced by a tuner.
+type Executor interface {
+	Execute(cmd commands.Command) error
+	// IsLazy reports whether Execute actually changes system state (false),
+	// or only records the command for later inspection, e.g. rendering it
+	// into a shell script (true).
+	IsLazy() bool
+}
+
+// VerifyingExecutor is implemented by executors that can apply a command
+// and then run an arbitrary verify callback, rolling the command back if
+// verify fails. Tuners that want that guarantee should type-assert their
+// Executor to this interface and fall back to plain Execute if it's not
+// implemented.
+type VerifyingExecutor interface {
+	Executor
+	ExecuteAndVerify(cmd commands.Command, verify func() error) error
+}
+
+type directExecutor struct{}
+
+// NewDirectExecutor returns an Executor that runs every Command immediately.
+func NewDirectExecutor() Executor {
+	return &directExecutor{}
+}
+
+func (*directExecutor) Execute(cmd commands.Command) error {
+	return cmd.Execute()
+}
+
+func (*directExecutor) IsLazy() bool {
+	return false
+}
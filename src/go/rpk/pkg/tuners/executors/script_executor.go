@@ -0,0 +1,38 @@
+// Copyright 2020 Vectorized, Inc.
+//
+// Use of this software is governed by the Business Source License
+// included in the file licenses/BSL.md
+//
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the Apache License, Version 2.0
+
+package executors
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/vectorizedio/redpanda/src/go/rpk/pkg/tuners/executors/commands"
+)
+
+type scriptRenderingExecutor struct {
+	out io.Writer
+}
+
+// NewScriptRenderingExecutor returns an Executor that, instead of applying
+// commands, writes their shell script rendering to out. This lets an
+// unprivileged `rpk tune` emit a script that an operator (or a privileged
+// init container) can review and run separately.
+func NewScriptRenderingExecutor(out io.Writer) Executor {
+	return &scriptRenderingExecutor{out}
+}
+
+func (executor *scriptRenderingExecutor) Execute(cmd commands.Command) error {
+	_, err := fmt.Fprintln(executor.out, cmd.RenderScript())
+	return err
+}
+
+func (*scriptRenderingExecutor) IsLazy() bool {
+	return true
+}
@@ -0,0 +1,263 @@
+// Copyright 2020 Vectorized, Inc.
+//
+// Use of this software is governed by the Business Source License
+// included in the file licenses/BSL.md
+//
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the Apache License, Version 2.0
+
+package executors
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"sync"
+	"syscall"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/afero"
+	"github.com/vectorizedio/redpanda/src/go/rpk/pkg/tuners/executors/commands"
+)
+
+// JournalFileName is the rollback journal's file name under the redpanda
+// data dir. NewTuneCommand and NewTuneRollbackCommand both derive their
+// journal path from JournalPath so they can never drift apart.
+const JournalFileName = "tune.journal"
+
+// JournalPath returns the on-disk path of the rollback journal given the
+// redpanda data directory.
+func JournalPath(redpandaDataDir string) string {
+	return filepath.Join(redpandaDataDir, JournalFileName)
+}
+
+// transactionalExecutor wraps another Executor and journals, to
+// journalPath, the rollback command for every command it applies. If any
+// command's Execute or its post-apply verification fails, or the process
+// receives SIGINT/SIGTERM, every journaled command is rolled back in
+// reverse order. The journal survives a crash: a later call to Rollback
+// (wired up to `rpk tune rollback`) replays whatever is left in it.
+type transactionalExecutor struct {
+	fs      afero.Fs
+	real    Executor
+	journal *journal
+
+	mu        sync.Mutex
+	sigCh     chan os.Signal
+	sigDoneCh chan struct{}
+}
+
+// NewTransactionalExecutor returns an Executor that records a rollback
+// journal at journalPath as it applies commands via real, and restores
+// every journaled command if a later command fails, its verify callback
+// (see ExecuteAndVerify) fails, or the process is interrupted.
+func NewTransactionalExecutor(
+	fs afero.Fs, real Executor, journalPath string,
+) Executor {
+	executor := &transactionalExecutor{
+		fs:      fs,
+		real:    real,
+		journal: newJournal(fs, journalPath),
+	}
+	executor.watchSignals()
+	return executor
+}
+
+func (executor *transactionalExecutor) Execute(cmd commands.Command) error {
+	return executor.ExecuteAndVerify(cmd, nil)
+}
+
+// ExecuteAndVerify journals cmd's rollback command, applies cmd, and then,
+// if verify is non-nil, runs it. On any failure, every command journaled so
+// far (including cmd) is rolled back, in reverse order, before the error is
+// returned.
+func (executor *transactionalExecutor) ExecuteAndVerify(
+	cmd commands.Command, verify func() error,
+) error {
+	executor.mu.Lock()
+	defer executor.mu.Unlock()
+
+	if reversible, ok := cmd.(commands.Reversible); ok {
+		rollbackCmd, err := reversible.Capture()
+		if err != nil {
+			return err
+		}
+		recordable, ok := rollbackCmd.(commands.Recordable)
+		if !ok {
+			return fmt.Errorf(
+				"rollback command for %v can't be journaled: %T isn't commands.Recordable",
+				cmd, rollbackCmd,
+			)
+		}
+		if err := executor.journal.record(recordable); err != nil {
+			return err
+		}
+	} else {
+		log.Debugf("Command %v is not reversible, can't be rolled back", cmd)
+	}
+
+	if err := executor.real.Execute(cmd); err != nil {
+		executor.rollbackLocked()
+		return err
+	}
+
+	if verify != nil {
+		if err := verify(); err != nil {
+			executor.rollbackLocked()
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (executor *transactionalExecutor) IsLazy() bool {
+	return executor.real.IsLazy()
+}
+
+// Rollback restores every command left in the journal, in reverse order,
+// and clears it (a lazy wrapped executor keeps it, see rollbackJournal).
+// It's safe to call on a journal left behind by a crashed `rpk tune`
+// invocation, e.g. from `rpk tune rollback`.
+func (executor *transactionalExecutor) Rollback() error {
+	executor.mu.Lock()
+	defer executor.mu.Unlock()
+	return executor.rollbackLocked()
+}
+
+func (executor *transactionalExecutor) rollbackLocked() error {
+	return rollbackJournal(executor.fs, executor.journal, executor.real)
+}
+
+// Rollback replays, in reverse order, whatever is left in the journal at
+// journalPath through executor and then clears it, unless executor is lazy.
+// It's exported so
+// `rpk tune rollback` can recover a journal left behind by a crashed
+// `rpk tune` invocation, without needing a live transactionalExecutor.
+func Rollback(fs afero.Fs, journalPath string, executor Executor) error {
+	return rollbackJournal(fs, newJournal(fs, journalPath), executor)
+}
+
+// rollbackJournal replays every entry in j, in reverse order, as a real
+// commands.Command bound to fs, through executor — the same abstraction the
+// forward-apply path uses. This keeps rollback testable against an injected
+// afero.Fs and respects executor.IsLazy(): rolling back through a lazy
+// (script-rendering) executor only renders the undo commands, it doesn't
+// mutate the filesystem.
+func rollbackJournal(fs afero.Fs, j *journal, executor Executor) error {
+	entries, err := j.readAll()
+	if err != nil {
+		return err
+	}
+	for i := len(entries) - 1; i >= 0; i-- {
+		cmd, err := commands.FromEntry(fs, entries[i])
+		if err != nil {
+			return err
+		}
+		log.Infof("Rolling back: %s", cmd.RenderScript())
+		if err := executor.Execute(cmd); err != nil {
+			return fmt.Errorf(
+				"rollback step %q failed: %w", cmd.RenderScript(), err,
+			)
+		}
+	}
+	if executor.IsLazy() {
+		// The undo commands were only rendered, so the journal is kept.
+		return nil
+	}
+	return j.clear()
+}
+
+// watchSignals rolls back the journal on SIGINT/SIGTERM before letting the
+// default handler re-raise the signal, so `rpk tune` doesn't leave the
+// kernel half-tuned when interrupted.
+func (executor *transactionalExecutor) watchSignals() {
+	executor.sigCh = make(chan os.Signal, 1)
+	executor.sigDoneCh = make(chan struct{})
+	signal.Notify(executor.sigCh, syscall.SIGINT, syscall.SIGTERM)
+	go func() {
+		select {
+		case sig := <-executor.sigCh:
+			log.Warnf("Received %s, rolling back in-flight tuning", sig)
+			if err := executor.Rollback(); err != nil {
+				log.Errorf("Rollback after %s failed: %v", sig, err)
+			}
+			signal.Stop(executor.sigCh)
+			process, _ := os.FindProcess(os.Getpid())
+			process.Signal(sig)
+		case <-executor.sigDoneCh:
+		}
+	}()
+}
+
+// Close stops watching for signals. Callers that construct a
+// transactionalExecutor for a single `rpk tune` run should defer Close once
+// tuning finishes successfully.
+func (executor *transactionalExecutor) Close() {
+	signal.Stop(executor.sigCh)
+	close(executor.sigDoneCh)
+}
+
+// journal persists, one JSON commands.JournalEntry per line, the rollback
+// command for every command recorded so far, so that a crashed process can
+// be reconstructed into real commands.Command values and rolled back by a
+// later `rpk tune rollback` invocation.
+type journal struct {
+	fs   afero.Fs
+	path string
+}
+
+func newJournal(fs afero.Fs, path string) *journal {
+	return &journal{fs, path}
+}
+
+func (j *journal) record(rollbackCmd commands.Recordable) error {
+	f, err := j.fs.OpenFile(
+		j.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644,
+	)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	line, err := json.Marshal(rollbackCmd.Entry())
+	if err != nil {
+		return err
+	}
+	_, err = f.Write(append(line, '\n'))
+	return err
+}
+
+func (j *journal) readAll() ([]commands.JournalEntry, error) {
+	f, err := j.fs.Open(j.path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var entries []commands.JournalEntry
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var entry commands.JournalEntry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			return nil, err
+		}
+		entries = append(entries, entry)
+	}
+	return entries, scanner.Err()
+}
+
+func (j *journal) clear() error {
+	err := j.fs.Remove(j.path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	return err
+}
@@ -0,0 +1,106 @@
+// Copyright 2020 Vectorized, Inc.
+//
+// Use of this software is governed by the Business Source License
+// included in the file licenses/BSL.md
+//
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the Apache License, Version 2.0
+
+package executors
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/require"
+	"github.com/vectorizedio/redpanda/src/go/rpk/pkg/tuners/executors/commands"
+)
+
+const journalPath = "/var/lib/redpanda/tune.journal"
+
+func TestTransactionalExecutorRollsBackInReverseOrderOnFailure(t *testing.T) {
+	fs := afero.NewMemMapFs()
+	require.NoError(t, afero.WriteFile(fs, "/a", []byte("original-a"), 0644))
+
+	executor := NewTransactionalExecutor(fs, NewDirectExecutor(), journalPath)
+	defer executor.(*transactionalExecutor).Close()
+
+	require.NoError(t, executor.Execute(commands.NewWriteFileCmd(fs, "/a", "tuned-a")))
+	require.NoError(t, executor.Execute(commands.NewWriteFileCmd(fs, "/b", "tuned-b")))
+
+	err := executor.Execute(failingCmd{})
+	require.Error(t, err)
+
+	a, err := afero.ReadFile(fs, "/a")
+	require.NoError(t, err)
+	require.Equal(t, "original-a", string(a))
+
+	// /b didn't exist before, so rollback should have removed it again.
+	exists, err := afero.Exists(fs, "/b")
+	require.NoError(t, err)
+	require.False(t, exists)
+
+	exists, err = afero.Exists(fs, journalPath)
+	require.NoError(t, err)
+	require.False(t, exists, "journal should be cleared after a successful rollback")
+}
+
+func TestTransactionalExecutorRollsBackOnVerifyFailure(t *testing.T) {
+	fs := afero.NewMemMapFs()
+	executor := NewTransactionalExecutor(fs, NewDirectExecutor(), journalPath)
+	defer executor.(*transactionalExecutor).Close()
+
+	verifying := executor.(VerifyingExecutor)
+	err := verifying.ExecuteAndVerify(
+		commands.NewWriteFileCmd(fs, "/a", "tuned-a"),
+		func() error { return errors.New("checker still failing") },
+	)
+	require.Error(t, err)
+
+	exists, err := afero.Exists(fs, "/a")
+	require.NoError(t, err)
+	require.False(t, exists)
+}
+
+func TestRollbackReplaysCrashedJournalAgainstInjectedFs(t *testing.T) {
+	fs := afero.NewMemMapFs()
+	require.NoError(t, afero.WriteFile(fs, "/a", []byte("original-a"), 0644))
+
+	executor := NewTransactionalExecutor(fs, NewDirectExecutor(), journalPath)
+	require.NoError(t, executor.Execute(commands.NewWriteFileCmd(fs, "/a", "tuned-a")))
+	executor.(*transactionalExecutor).Close()
+
+	// Simulate a crash: a fresh process only has the on-disk journal, not
+	// the transactionalExecutor that wrote it.
+	require.NoError(t, Rollback(fs, journalPath, NewDirectExecutor()))
+
+	a, err := afero.ReadFile(fs, "/a")
+	require.NoError(t, err)
+	require.Equal(t, "original-a", string(a))
+}
+
+func TestRollbackThroughLazyExecutorDoesNotTouchFs(t *testing.T) {
+	fs := afero.NewMemMapFs()
+	require.NoError(t, afero.WriteFile(fs, "/a", []byte("tuned-a"), 0644))
+
+	journal := newJournal(fs, journalPath)
+	require.NoError(t, journal.record(
+		commands.NewWriteFileCmd(fs, "/a", "original-a").(commands.Recordable),
+	))
+
+	var script strings.Builder
+	require.NoError(t, rollbackJournal(fs, journal, NewScriptRenderingExecutor(&script)))
+
+	require.Contains(t, script.String(), "/a")
+	a, err := afero.ReadFile(fs, "/a")
+	require.NoError(t, err)
+	require.Equal(t, "tuned-a", string(a), "a lazy rollback must not mutate the fs")
+}
+
+type failingCmd struct{}
+
+func (failingCmd) Execute() error       { return errors.New("boom") }
+func (failingCmd) RenderScript() string { return "false" }